@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/deluan/navidrome/scanner"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	scanProfCmd := &cobra.Command{
+		Use:   "scanprof",
+		Short: "Inspect a scanner sampling profile",
+		Long:  "Tools for working with the sampling profiles recorded via ND_STACKLOG_PATH during a library scan.",
+	}
+	scanProfCmd.AddCommand(scanProfSummarizeCmd)
+	rootCmd.AddCommand(scanProfCmd)
+}
+
+var topN int
+
+var scanProfSummarizeCmd = &cobra.Command{
+	Use:   "summarize <file>",
+	Short: "Print the top call stacks by sample count",
+	Long: "Reads a file written by the scanner's stacklog profiler (ND_STACKLOG_PATH) and prints its " +
+		"call stacks ordered by how often they were sampled, so you can tell whether scan time is going " +
+		"into loadDir, tag reading, artwork extraction or DB writes without running a full pprof session.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		samples, err := scanner.SummarizeStacklog(args[0], topN)
+		if err != nil {
+			return err
+		}
+		for i, s := range samples {
+			fmt.Printf("#%d: %d samples\n%s\n", i+1, s.Count, s.Stack)
+		}
+		return nil
+	},
+}
+
+func init() {
+	scanProfSummarizeCmd.Flags().IntVar(&topN, "top", 20, "number of distinct call stacks to print")
+}
@@ -0,0 +1,6 @@
+package consts
+
+// IgnoreFile is the name of the file, present in a music folder directory,
+// whose lines are gitignore-style patterns excluding matching files and
+// subdirectories from that point down in the tree.
+const IgnoreFile = ".ndignore"
@@ -0,0 +1,156 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Sentinel errors classifying why a directory couldn't be walked. wrapPathErr
+// attaches them to the underlying *os.PathError while keeping that error as
+// the Unwrap target, so errors.Is/errors.As still reach through to it when
+// callers need the raw syscall error.
+var (
+	ErrNotADir       = errors.New("not a directory")
+	ErrUnreadableDir = errors.New("unreadable directory")
+	ErrBrokenSymlink = errors.New("broken symlink")
+	ErrSkipped       = errors.New("skipped by callback")
+)
+
+// wrapPathErr wraps err with sentinel, preserving err as the Unwrap target so
+// errors.Is(result, sentinel) and errors.Is(result, err) both hold.
+func wrapPathErr(sentinel error, err error) error {
+	return &pathError{sentinel: sentinel, err: err}
+}
+
+type pathError struct {
+	sentinel error
+	err      error
+}
+
+func (e *pathError) Error() string        { return e.sentinel.Error() + ": " + e.err.Error() }
+func (e *pathError) Unwrap() error        { return e.err }
+func (e *pathError) Is(target error) bool { return target == e.sentinel }
+
+// ErrorKind identifies which sentinel a recorded error belongs to, so a
+// ScanReport can aggregate counts without errors.Is-ing every entry.
+type ErrorKind int
+
+const (
+	KindUnreadableDir ErrorKind = iota
+	KindNotADir
+	KindBrokenSymlink
+	KindSkipped
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindNotADir:
+		return "not a directory"
+	case KindBrokenSymlink:
+		return "broken symlink"
+	case KindSkipped:
+		return "skipped"
+	default:
+		return "unreadable directory"
+	}
+}
+
+// maxSamplePaths bounds how many offending paths a ScanReport keeps per
+// ErrorKind, so a scan over a library with thousands of bad entries
+// doesn't balloon the report.
+const maxSamplePaths = 10
+
+type kindStats struct {
+	Count int
+	Paths []string
+}
+
+// ScanReport aggregates the errors a Walker ran into while walking a
+// directory tree: how many of each ErrorKind it saw, and the first few
+// offending paths, so callers like the /api/scanner/status endpoint can
+// surface "42 unreadable directories, 3 broken symlinks" without grepping
+// logs.
+type ScanReport struct {
+	mu    sync.Mutex
+	stats map[ErrorKind]*kindStats
+}
+
+// NewScanReport creates an empty ScanReport.
+func NewScanReport() *ScanReport {
+	return &ScanReport{stats: map[ErrorKind]*kindStats{}}
+}
+
+func (r *ScanReport) record(kind ErrorKind, path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.stats[kind]
+	if s == nil {
+		s = &kindStats{}
+		r.stats[kind] = s
+	}
+	s.Count++
+	if len(s.Paths) < maxSamplePaths {
+		s.Paths = append(s.Paths, path)
+	}
+}
+
+// Count returns how many errors of kind were recorded.
+func (r *ScanReport) Count(kind ErrorKind) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s := r.stats[kind]; s != nil {
+		return s.Count
+	}
+	return 0
+}
+
+// Paths returns up to maxSamplePaths offending paths recorded for kind.
+func (r *ScanReport) Paths(kind ErrorKind) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s := r.stats[kind]; s != nil {
+		return append([]string(nil), s.Paths...)
+	}
+	return nil
+}
+
+// Empty returns true if no errors were recorded at all.
+func (r *ScanReport) Empty() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.stats {
+		if s.Count > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders a short human-readable summary, e.g.
+// "42 unreadable directories, 3 broken symlinks".
+func (r *ScanReport) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.stats) == 0 {
+		return "no errors"
+	}
+	var parts []string
+	for _, kind := range []ErrorKind{KindUnreadableDir, KindNotADir, KindBrokenSymlink, KindSkipped} {
+		if s := r.stats[kind]; s != nil && s.Count > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", s.Count, pluralize(kind.String(), s.Count)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func pluralize(noun string, count int) string {
+	if count == 1 {
+		return noun
+	}
+	if strings.HasSuffix(noun, "y") {
+		return noun[:len(noun)-1] + "ies"
+	}
+	return noun + "s"
+}
@@ -0,0 +1,135 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/deluan/navidrome/storage"
+	"github.com/deluan/navidrome/storage/basicfs"
+)
+
+func TestScanReport_RecordAndCount(t *testing.T) {
+	r := NewScanReport()
+	if !r.Empty() {
+		t.Fatal("a fresh ScanReport should be Empty")
+	}
+
+	r.record(KindUnreadableDir, "/music/a")
+	r.record(KindUnreadableDir, "/music/b")
+	r.record(KindBrokenSymlink, "/music/c")
+
+	if r.Empty() {
+		t.Error("a ScanReport with recorded errors should not be Empty")
+	}
+	if got := r.Count(KindUnreadableDir); got != 2 {
+		t.Errorf("Count(KindUnreadableDir) = %d, want 2", got)
+	}
+	if got := r.Count(KindBrokenSymlink); got != 1 {
+		t.Errorf("Count(KindBrokenSymlink) = %d, want 1", got)
+	}
+	if got := r.Count(KindNotADir); got != 0 {
+		t.Errorf("Count(KindNotADir) = %d, want 0", got)
+	}
+}
+
+func TestScanReport_PathsAreCapped(t *testing.T) {
+	r := NewScanReport()
+	for i := 0; i < maxSamplePaths+5; i++ {
+		r.record(KindUnreadableDir, fmt.Sprintf("/music/%d", i))
+	}
+
+	if got := r.Count(KindUnreadableDir); got != maxSamplePaths+5 {
+		t.Errorf("Count = %d, want %d", got, maxSamplePaths+5)
+	}
+	if got := len(r.Paths(KindUnreadableDir)); got != maxSamplePaths {
+		t.Errorf("len(Paths) = %d, want %d (capped)", got, maxSamplePaths)
+	}
+}
+
+func TestScanReport_String(t *testing.T) {
+	r := NewScanReport()
+	if got := r.String(); got != "no errors" {
+		t.Errorf("String() = %q, want %q", got, "no errors")
+	}
+
+	r.record(KindUnreadableDir, "/music/a")
+	r.record(KindBrokenSymlink, "/music/b")
+	r.record(KindBrokenSymlink, "/music/c")
+
+	want := "1 unreadable directory, 2 broken symlinks"
+	if got := r.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestWalker_Fail_SkipsByDefault(t *testing.T) {
+	w := NewWalker(1, nil, false, nil)
+	err := w.fail(KindUnreadableDir, ErrUnreadableDir, "/music/bad", fmt.Errorf("permission denied"))
+	if !errors.Is(err, SkipThis) {
+		t.Fatalf("fail() = %v, want SkipThis", err)
+	}
+	if got := w.Report.Count(KindUnreadableDir); got != 1 {
+		t.Errorf("Report.Count(KindUnreadableDir) = %d, want 1", got)
+	}
+}
+
+func TestWalker_Fail_FailFastWrapsError(t *testing.T) {
+	w := NewWalker(1, nil, true, nil)
+	cause := fmt.Errorf("permission denied")
+	err := w.fail(KindUnreadableDir, ErrUnreadableDir, "/music/bad", cause)
+
+	if !errors.Is(err, ErrUnreadableDir) {
+		t.Fatalf("fail() = %v, want a wrapped ErrUnreadableDir", err)
+	}
+	if got := w.Report.Count(KindUnreadableDir); got != 1 {
+		t.Errorf("Report.Count(KindUnreadableDir) = %d, want 1 (recorded even though FailFast aborts)", got)
+	}
+}
+
+// unreadableAt wraps a storage.FS and makes ReadDir fail for one exact
+// path, simulating a directory Walk can't list (e.g. permission denied)
+// without depending on OS file permissions, which the test process's own
+// user may simply bypass.
+type unreadableAt struct {
+	storage.FS
+	path string
+}
+
+func (f *unreadableAt) ReadDir(name string) ([]storage.DirEntry, error) {
+	if name == f.path {
+		return nil, fmt.Errorf("permission denied")
+	}
+	return f.FS.ReadDir(name)
+}
+
+// TestWalk_RecordsUnreadableSubdirectory is an end-to-end check that an
+// unreadable subdirectory is recorded in the real Walk's ScanReport, not
+// just in the unit-level fail() calls above. Previously this subdirectory
+// would have been silently dropped by a pre-check (isDirReadable) that
+// never went through w.fail, so ScanReport never saw the single most
+// common real scan error.
+func TestWalk_RecordsUnreadableSubdirectory(t *testing.T) {
+	root := t.TempDir()
+	bad := filepath.Join(root, "bad")
+	if err := os.Mkdir(bad, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := &unreadableAt{FS: basicfs.New(""), path: bad}
+	w := NewWalker(2, fs, false, nil)
+
+	results := make(walkResults)
+	go drain(results)
+	if err := w.Walk(context.Background(), root, results); err != nil {
+		t.Fatalf("Walk returned an error: %v", err)
+	}
+	close(results)
+
+	if got := w.Report.Count(KindUnreadableDir); got != 1 {
+		t.Errorf("Report.Count(KindUnreadableDir) = %d, want 1", got)
+	}
+}
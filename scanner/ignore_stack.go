@@ -0,0 +1,97 @@
+package scanner
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/deluan/navidrome/consts"
+	"github.com/deluan/navidrome/storage"
+	"github.com/deluan/navidrome/utils/ignore"
+)
+
+// ignoreStack is an immutable chain of ignore.Sets, one per ancestor
+// directory that had a consts.IgnoreFile, from the music folder root down
+// to (but not including) the current directory. Being immutable (each
+// push returns a new node instead of mutating in place) makes it safe to
+// share across the Walker's concurrent recursion: siblings fork off the
+// same parent node without racing each other.
+type ignoreStack struct {
+	parent *ignoreStack
+	base   string
+	set    *ignore.Set
+}
+
+// newIgnoreStack seeds a root ignoreStack from the global IgnoredPatterns
+// config option, rooted at root.
+func newIgnoreStack(root string, globalPatterns []string) (*ignoreStack, error) {
+	set, err := ignore.Parse(globalPatterns)
+	if err != nil {
+		return nil, err
+	}
+	return &ignoreStack{base: root, set: set}, nil
+}
+
+// push reads dir's consts.IgnoreFile (if any) through fs and returns the
+// ignoreStack children of dir should use. If dir has no ignore file, the
+// same stack is reused instead of growing it.
+func (s *ignoreStack) push(fs storage.FS, dir string) (*ignoreStack, error) {
+	f, err := fs.Open(filepath.Join(dir, consts.IgnoreFile))
+	if err != nil {
+		return s, nil
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	set, err := ignore.Parse(splitLines(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	if set.Empty() {
+		return s, nil
+	}
+	return &ignoreStack{parent: s, base: dir, set: set}, nil
+}
+
+// match reports whether absPath (isDir reflecting whether it's itself a
+// directory) is ignored by any Set in the stack, checking from the
+// root down to the leaf so that a deeper, more specific .ndignore can
+// override a shallower one, exactly like nested .gitignore files do.
+func (s *ignoreStack) match(absPath string, isDir bool) bool {
+	var levels []*ignoreStack
+	for n := s; n != nil; n = n.parent {
+		levels = append(levels, n)
+	}
+
+	ignored := false
+	for i := len(levels) - 1; i >= 0; i-- {
+		n := levels[i]
+		rel, err := filepath.Rel(n.base, absPath)
+		if err != nil {
+			continue
+		}
+		for _, p := range n.set.Patterns {
+			if p.Match(filepath.ToSlash(rel), isDir) {
+				ignored = !p.Negate
+			}
+		}
+	}
+	return ignored
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			lines = append(lines, s[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		lines = append(lines, s[start:])
+	}
+	return lines
+}
@@ -0,0 +1,120 @@
+package scanner
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/deluan/navidrome/log"
+)
+
+// stacklogPathEnv, when set, turns on a lightweight sampling profiler for
+// the duration of a scan. It's named after (and writes a format inspired
+// by) Google's slowjam stacklog.
+const stacklogPathEnv = "ND_STACKLOG_PATH"
+
+const stacklogInterval = 100 * time.Millisecond
+
+// stacklogBufSize is generous enough to hold every goroutine's stack for a
+// scan with a few hundred workers; runtime.Stack silently truncates if a
+// snapshot doesn't fit, which just cuts off the goroutines at the end of
+// the dump, not the whole sample.
+const stacklogBufSize = 4 << 20
+
+// stacklog periodically snapshots every goroutine's stack and appends it,
+// length-prefixed and gzip-compressed, to a file. Frames are read back by
+// SummarizeStacklog.
+type stacklog struct {
+	mu   sync.Mutex
+	w    *bufio.Writer
+	gz   *gzip.Writer
+	file *os.File
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newStacklog(path string) (*stacklog, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(f)
+	return &stacklog{
+		file: f,
+		gz:   gz,
+		w:    bufio.NewWriter(gz),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}, nil
+}
+
+func (s *stacklog) run() {
+	defer close(s.done)
+	buf := make([]byte, stacklogBufSize)
+	ticker := time.NewTicker(stacklogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			n := runtime.Stack(buf, true)
+			s.writeFrame(buf[:n])
+		}
+	}
+}
+
+// writeFrame appends one length-prefixed, gzip-compressed stack snapshot.
+func (s *stacklog) writeFrame(frame []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(frame)))
+	_, _ = s.w.Write(length[:])
+	_, _ = s.w.Write(frame)
+}
+
+// Close stops the sampling goroutine and flushes everything to disk.
+func (s *stacklog) Close() error {
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	if err := s.gz.Close(); err != nil {
+		return err
+	}
+	return s.file.Close()
+}
+
+// startStacklog starts the sampling profiler if ND_STACKLOG_PATH is set in
+// the environment, and always returns a function to stop it. When the env
+// var is unset, the returned function is a no-op and nothing is started,
+// so enabling this feature is zero-cost in the common case.
+func startStacklog(ctx context.Context) func() {
+	path := os.Getenv(stacklogPathEnv)
+	if path == "" {
+		return func() {}
+	}
+	sl, err := newStacklog(path)
+	if err != nil {
+		log.Error(ctx, "Could not start stacklog profiler", "path", path, err)
+		return func() {}
+	}
+	log.Info(ctx, "Sampling profiler enabled for this scan", "path", path)
+	go sl.run()
+	return func() {
+		if err := sl.Close(); err != nil {
+			log.Error(ctx, "Error closing stacklog profiler", err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package scanner
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// StackSample is one distinct goroutine call stack seen while sampling, and
+// how many times it was seen (summed across every goroutine and every
+// sample that matched it).
+type StackSample struct {
+	Stack string
+	Count int
+}
+
+// goroutineHeaderRe matches the "goroutine 7 [chan receive, 5 minutes]:"
+// line runtime.Stack(buf, true) prints before each goroutine's frames.
+var goroutineHeaderRe = regexp.MustCompile(`^goroutine \d+ \[([^]]*)\]:$`)
+
+// durationSuffixRe strips the ", N minutes"/", N seconds" clause runtime.Stack
+// appends to a goroutine's state once it's been parked a while. It changes
+// on every sample even for a goroutine that hasn't moved at all, so leaving
+// it in the key would make every sample of that same goroutine count as a
+// distinct stack.
+var durationSuffixRe = regexp.MustCompile(`,\s*\d+(\.\d+)?\s*\w+$`)
+
+// backgroundGoroutines names runtime-internal goroutines (GC workers, the
+// background sweeper/scavenger, sysmon, ...) that exist in every process
+// regardless of what the scan itself is doing. They're dropped entirely
+// rather than normalized, since they'd otherwise dilute the "top call
+// stacks" a user is actually trying to see.
+var backgroundGoroutines = []string{
+	"runtime.gcBgMarkWorker",
+	"runtime.bgsweep",
+	"runtime.bgscavenge",
+	"runtime.forcegchelper",
+	"runtime.sysmon",
+	"runtime.timerproc",
+	"runtime.runfinq",
+}
+
+// SummarizeStacklog reads a file written by the stacklog profiler
+// (ND_STACKLOG_PATH) and returns its distinct call stacks ordered by sample
+// count, most frequent first, capped at topN (topN <= 0 means no cap).
+//
+// Each frame in the file is runtime.Stack(buf, true)'s dump of every
+// goroutine at one instant; SummarizeStacklog splits it back into
+// individual goroutine stacks (see splitGoroutineStacks) before counting,
+// so repeated samples of the same call stack are actually grouped
+// together instead of every goroutine ID/wait-duration combination
+// counting as its own one-off "frame". This is what backs
+// `navidrome scanprof summarize`.
+func SummarizeStacklog(path string, topN int) ([]StackSample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening stacklog: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading stacklog: %w", err)
+	}
+	defer gz.Close()
+
+	counts := map[string]int{}
+	r := bufio.NewReader(gz)
+	var length [4]byte
+	for {
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("reading stacklog frame: %w", err)
+		}
+		frame := make([]byte, binary.BigEndian.Uint32(length[:]))
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return nil, fmt.Errorf("reading stacklog frame: %w", err)
+		}
+		for _, stack := range splitGoroutineStacks(frame) {
+			counts[stack]++
+		}
+	}
+
+	samples := make([]StackSample, 0, len(counts))
+	for stack, count := range counts {
+		samples = append(samples, StackSample{Stack: stack, Count: count})
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Count > samples[j].Count })
+	if topN > 0 && len(samples) > topN {
+		samples = samples[:topN]
+	}
+	return samples, nil
+}
+
+// splitGoroutineStacks splits one runtime.Stack(buf, true) dump into its
+// individual goroutine stacks. Each stack's header is normalized to drop
+// its goroutine ID (never reused, so it's pure noise) and any wait-duration
+// annotation (changes every sample); background runtime goroutines are
+// dropped entirely rather than normalized.
+func splitGoroutineStacks(frame []byte) []string {
+	var stacks []string
+	var header string
+	var body []string
+
+	flush := func() {
+		if header == "" || isBackgroundGoroutine(body) {
+			return
+		}
+		stacks = append(stacks, header+"\n"+strings.Join(body, "\n"))
+	}
+
+	s := bufio.NewScanner(strings.NewReader(string(frame)))
+	s.Buffer(make([]byte, 0, 64*1024), stacklogBufSize)
+	for s.Scan() {
+		line := s.Text()
+		if m := goroutineHeaderRe.FindStringSubmatch(line); m != nil {
+			flush()
+			state := durationSuffixRe.ReplaceAllString(m[1], "")
+			header = "goroutine [" + state + "]:"
+			body = nil
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+	return stacks
+}
+
+func isBackgroundGoroutine(body []string) bool {
+	for _, line := range body {
+		for _, name := range backgroundGoroutines {
+			if strings.Contains(line, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
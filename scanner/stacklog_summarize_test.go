@@ -0,0 +1,109 @@
+package scanner
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestStacklog writes frames to a file in the same length-prefixed,
+// gzip-compressed format stacklog.writeFrame produces, so SummarizeStacklog
+// can be tested without actually running the sampling goroutine.
+func writeTestStacklog(t *testing.T, frames ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stacklog")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	for _, frame := range frames {
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(len(frame)))
+		if _, err := gz.Write(length[:]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gz.Write([]byte(frame)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const frameA = `goroutine 1 [running]:
+main.A()
+	/src/a.go:10 +0x1
+
+goroutine 2 [chan receive, 5 minutes]:
+main.B()
+	/src/b.go:20 +0x2
+`
+
+// frameB reuses the exact same two call stacks as frameA, but under
+// different goroutine IDs and a different wait duration, simulating two
+// samples taken later in the same scan.
+const frameB = `goroutine 3 [running]:
+main.A()
+	/src/a.go:10 +0x1
+
+goroutine 7 [chan receive, 12 minutes]:
+main.B()
+	/src/b.go:20 +0x2
+`
+
+func TestSummarizeStacklog_AggregatesAcrossGoroutineIDsAndDurations(t *testing.T) {
+	path := writeTestStacklog(t, frameA, frameB)
+
+	samples, err := SummarizeStacklog(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("got %d distinct stacks, want 2: %+v", len(samples), samples)
+	}
+	for _, s := range samples {
+		if s.Count != 2 {
+			t.Errorf("stack %q: Count = %d, want 2", s.Stack, s.Count)
+		}
+	}
+}
+
+func TestSummarizeStacklog_DropsBackgroundGoroutines(t *testing.T) {
+	frame := `goroutine 1 [running]:
+main.A()
+	/src/a.go:10 +0x1
+
+goroutine 2 [running]:
+runtime.bgsweep(0x0)
+	/usr/local/go/src/runtime/mgcsweep.go:100 +0x1
+`
+	path := writeTestStacklog(t, frame)
+
+	samples, err := SummarizeStacklog(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 {
+		t.Fatalf("got %d distinct stacks, want 1 (background goroutine should be dropped): %+v", len(samples), samples)
+	}
+}
+
+func TestSummarizeStacklog_TopNCapsResults(t *testing.T) {
+	path := writeTestStacklog(t, frameA)
+
+	samples, err := SummarizeStacklog(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 {
+		t.Fatalf("got %d samples, want 1 (topN=1)", len(samples))
+	}
+}
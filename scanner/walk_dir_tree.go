@@ -2,14 +2,18 @@ package scanner
 
 import (
 	"context"
-	"io/ioutil"
+	"errors"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/deluan/navidrome/consts"
 	"github.com/deluan/navidrome/log"
+	"github.com/deluan/navidrome/storage"
+	"github.com/deluan/navidrome/storage/basicfs"
 	"github.com/deluan/navidrome/utils"
 )
 
@@ -24,109 +28,370 @@ type (
 	walkResults = chan dirStats
 )
 
-func walkDirTree(ctx context.Context, rootFolder string, results walkResults) error {
-	err := walkFolder(ctx, rootFolder, rootFolder, results)
+// SkipThis is returned by Walker.fail (and so by loadDir) when FailFast is
+// off, to indicate that the directory being loaded should be pruned from the
+// walk. It does not abort the rest of the tree, only the subtree rooted at
+// that directory. Named after godirwalk.SkipThis; Walker takes no
+// caller-supplied callback, so it is only ever produced inside this package.
+var SkipThis = errors.New("scanner: skip this directory")
+
+// Walker walks a directory tree concurrently, with a fixed pool of
+// NumWorkers goroutines processing directories off a shared queue. It
+// emits a dirStats for every directory it visits on the results channel,
+// preserving the invariant the original single-goroutine walker had: a
+// directory's stats are only sent after every one of its children has
+// already been sent.
+//
+// FS is the storage backend the tree lives on; it defaults to the local
+// filesystem (basicfs) so existing MusicFolder paths keep working
+// unchanged, but a music folder configured with a URI such as
+// "s3://bucket/prefix" resolves to a different FS via storage.New. A URI
+// whose scheme has no registered backend (there is no "smb" one, for
+// instance) makes walkDirTree fall back to basicfs.
+//
+// Every error the walk runs into (unreadable directory, broken symlink,
+// etc.) is recorded in Report. FailFast additionally controls whether such
+// an error aborts the whole walk (true) or is recorded and the offending
+// subtree is simply skipped (false, the default).
+type Walker struct {
+	NumWorkers     int
+	FS             storage.FS
+	FailFast       bool
+	Report         *ScanReport
+	IgnorePatterns []string
+}
+
+// NewWalker creates a Walker with the given worker pool size, rooted at
+// fs. If numWorkers is <= 0, runtime.NumCPU() is used. If fs is nil, the
+// local filesystem (basicfs) is used. ignorePatterns seeds the root of the
+// walk's ignoreStack, in addition to whatever consts.IgnoreFile files are
+// found further down the tree.
+func NewWalker(numWorkers int, fs storage.FS, failFast bool, ignorePatterns []string) *Walker {
+	if numWorkers <= 0 {
+		numWorkers = runtime.NumCPU()
+	}
+	if fs == nil {
+		fs = basicfs.New("")
+	}
+	return &Walker{
+		NumWorkers:     numWorkers,
+		FS:             fs,
+		FailFast:       failFast,
+		Report:         NewScanReport(),
+		IgnorePatterns: ignorePatterns,
+	}
+}
+
+// GlobalIgnorePatterns seeds every Walker's root ignoreStack, on top of
+// whatever per-directory consts.IgnoreFile files it finds. It corresponds
+// to the IgnoredPatterns server config option; the code that loads
+// Navidrome's configuration is expected to set this once at startup,
+// before any scan runs.
+var GlobalIgnorePatterns []string
+
+// walkDirTree walks rootFolder and sends its dirStats to results, closing
+// results when done. failFast is passed straight through to the underlying
+// Walker: when true, the first unreadable directory, broken symlink, etc.
+// aborts the whole walk; when false, it's recorded in the returned
+// *ScanReport and only that offending subtree is skipped. Callers such as
+// the full/incremental scanner (and, through it, /api/scanner/status) are
+// expected to choose failFast and inspect the returned report.
+func walkDirTree(ctx context.Context, rootFolder string, failFast bool, results walkResults) (*ScanReport, error) {
+	stopStacklog := startStacklog(ctx)
+	defer stopStacklog()
+
+	fs, err := storage.New(rootFolder)
+	if err != nil {
+		fs = basicfs.New(rootFolder)
+	}
+	w := NewWalker(runtime.NumCPU(), fs, failFast, GlobalIgnorePatterns)
+	err = w.Walk(ctx, rootFolder, results)
 	if err != nil {
 		log.Error(ctx, "Error loading directory tree", err)
 	}
+	if !w.Report.Empty() {
+		log.Warn(ctx, "Errors found while scanning directory tree", "report", w.Report.String())
+	}
 	close(results)
-	return err
+	return w.Report, err
 }
 
-func walkFolder(ctx context.Context, rootPath string, currentFolder string, results walkResults) error {
-	children, stats, err := loadDir(ctx, currentFolder)
+// dirNode is one directory in the tree being walked. pending counts how
+// many of its children haven't finished their own subtree yet; once it
+// reaches zero (every child, grandchild, etc. has sent its dirStats),
+// node's own stats are sent, so a parent's stats always arrive after its
+// children's regardless of which worker happens to finish last.
+type dirNode struct {
+	path     string
+	parent   *dirNode
+	stack    *ignoreStack
+	stats    dirStats
+	children []string
+	skip     bool
+	err      error
+	pending  int32
+}
+
+// Walk traverses rootFolder and sends a dirStats for every directory found
+// to results. It does not close results; callers own that channel.
+//
+// Directories are handed out to a fixed pool of w.NumWorkers goroutines
+// pulling from a shared, dynamically-growing queue. (An earlier version of
+// this walker spawned one goroutine per directory and held a worker-pool
+// slot for that goroutine's entire subtree; that deadlocked on any tree
+// deeper than NumWorkers levels, since an inner directory could never
+// acquire a slot that was held by one of its own ancestors.) A directory
+// only ever occupies a worker for as long as its own entries are being
+// read; each of its children is a separate, independently schedulable job.
+func (w *Walker) Walk(ctx context.Context, rootFolder string, results walkResults) error {
+	rootStack, err := newIgnoreStack(rootFolder, w.IgnorePatterns)
 	if err != nil {
 		return err
 	}
-	for _, c := range children {
-		err := walkFolder(ctx, rootPath, c, results)
-		if err != nil {
-			return err
+
+	in, out := newDirNodeQueue()
+
+	var remaining int64 = 1
+	var aborted int32
+	var errOnce sync.Once
+	var firstErr error
+
+	numWorkers := w.NumWorkers
+	if numWorkers <= 0 {
+		numWorkers = 1
+	}
+	var workers sync.WaitGroup
+	workers.Add(numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go func() {
+			defer workers.Done()
+			for node := range out {
+				w.processNode(ctx, node, atomic.LoadInt32(&aborted) != 0)
+				if node.err != nil {
+					errOnce.Do(func() {
+						firstErr = node.err
+						atomic.StoreInt32(&aborted, 1)
+					})
+				}
+				w.visit(node, in, &remaining, results)
+			}
+		}()
+	}
+
+	in <- &dirNode{path: rootFolder, stack: rootStack}
+	workers.Wait()
+
+	return firstErr
+}
+
+// processNode loads node's directory (unless the walk has already been
+// aborted by a fail-fast error elsewhere), classifying the outcome onto
+// node itself: its stats, whether it should be skipped (no stats sent),
+// its children (if any), and any fail-fast error to propagate.
+func (w *Walker) processNode(ctx context.Context, node *dirNode, aborted bool) {
+	if aborted {
+		node.skip = true
+		return
+	}
+
+	stack, err := node.stack.push(w.FS, node.path)
+	if err != nil {
+		log.Error(ctx, "Error reading ignore file", "dir", node.path, err)
+	}
+	node.stack = stack
+
+	children, stats, err := w.loadDir(ctx, node.path, node.stack)
+	if errors.Is(err, SkipThis) {
+		node.skip = true
+		return
+	}
+	if err != nil {
+		node.skip = true
+		node.err = err
+		return
+	}
+
+	node.stats = stats
+	node.children = children
+}
+
+// visit finishes bookkeeping for node after processNode has run: it
+// enqueues node's children (if any), or otherwise completes node (and
+// cascades completion up through any ancestors this was the last pending
+// child of). remaining tracks the total count of dirNodes still to be
+// processed; once it reaches zero every job has either completed or been
+// enqueued-and-accounted-for, so the queue is closed, which lets the
+// workers' range loops (and therefore Walk) return.
+func (w *Walker) visit(node *dirNode, in chan<- *dirNode, remaining *int64, results walkResults) {
+	if !node.skip && len(node.children) > 0 {
+		atomic.StoreInt32(&node.pending, int32(len(node.children)))
+		atomic.AddInt64(remaining, int64(len(node.children)))
+		for _, c := range node.children {
+			in <- &dirNode{path: c, parent: node, stack: node.stack}
+		}
+	} else {
+		complete(node, results)
+	}
+	if atomic.AddInt64(remaining, -1) == 0 {
+		close(in)
+	}
+}
+
+// complete sends node's own stats (unless it was skipped) to results,
+// then, if that was the last pending child of node's parent, does the
+// same for the parent, and so on up the tree.
+func complete(node *dirNode, results walkResults) {
+	for n := node; n != nil; n = n.parent {
+		if !n.skip {
+			dir := filepath.Clean(n.path)
+			log.Trace(context.Background(), "Found directory", "dir", dir, "audioCount", n.stats.AudioFilesCount,
+				"hasImages", n.stats.HasImages, "hasPlaylist", n.stats.HasPlaylist)
+			n.stats.Path = dir
+			results <- n.stats
+		}
+		if n.parent == nil {
+			return
+		}
+		if atomic.AddInt32(&n.parent.pending, -1) != 0 {
+			return
 		}
 	}
+}
 
-	dir := filepath.Clean(currentFolder)
-	log.Trace(ctx, "Found directory", "dir", dir, "audioCount", stats.AudioFilesCount,
-		"hasImages", stats.HasImages, "hasPlaylist", stats.HasPlaylist)
-	stats.Path = dir
-	results <- stats
+// newDirNodeQueue returns a producer/consumer pair of channels backed by an
+// unbounded internal buffer. Workers both consume from out and, while
+// handling a node, may need to produce more work into in (that node's
+// children) — a plain buffered channel would deadlock once full with every
+// worker blocked trying to send, since nothing would be left to drain it.
+func newDirNodeQueue() (chan<- *dirNode, <-chan *dirNode) {
+	in := make(chan *dirNode)
+	out := make(chan *dirNode)
+	go func() {
+		defer close(out)
+		var buf []*dirNode
+		for {
+			if len(buf) == 0 {
+				v, ok := <-in
+				if !ok {
+					return
+				}
+				buf = append(buf, v)
+				continue
+			}
+			select {
+			case v, ok := <-in:
+				if !ok {
+					for _, q := range buf {
+						out <- q
+					}
+					return
+				}
+				buf = append(buf, v)
+			case out <- buf[0]:
+				buf = buf[1:]
+			}
+		}
+	}()
+	return in, out
+}
 
-	return nil
+// fail records cause against kind and path in w.Report, then reports back
+// to the caller how to proceed: if w.FailFast, the sentinel-wrapped error is
+// returned so it propagates out of Walk and aborts the whole tree; otherwise
+// SkipThis is returned so only the offending directory (or entry) is pruned
+// and the rest of the walk continues.
+func (w *Walker) fail(kind ErrorKind, sentinel error, path string, cause error) error {
+	w.Report.record(kind, path)
+	if w.FailFast {
+		return wrapPathErr(sentinel, cause)
+	}
+	return SkipThis
 }
 
-func loadDir(ctx context.Context, dirPath string) (children []string, stats dirStats, err error) {
-	dirInfo, err := os.Stat(dirPath)
+// loadDir reads the contents of dirPath (through w.FS) and returns its
+// child directories (already filtered for ignored ones; an unreadable
+// child is still queued and reports its own error once its turn to be
+// loaded comes up, rather than being probed here) plus the aggregate
+// stats for the files it directly contains. FS.ReadDir
+// entries carry their type without an extra stat, so only non-directory
+// entries (which we need the ModTime of) and symlinks are stat'ed.
+func (w *Walker) loadDir(ctx context.Context, dirPath string, stack *ignoreStack) (children []string, stats dirStats, err error) {
+	dirInfo, err := w.FS.Stat(dirPath)
 	if err != nil {
 		log.Error(ctx, "Error stating dir", "path", dirPath, err)
+		err = w.fail(KindNotADir, ErrNotADir, dirPath, err)
 		return
 	}
 	stats.ModTime = dirInfo.ModTime()
 
-	files, err := ioutil.ReadDir(dirPath)
+	entries, err := w.FS.ReadDir(dirPath)
 	if err != nil {
 		log.Error(ctx, "Error reading dir", "path", dirPath, err)
+		err = w.fail(KindUnreadableDir, ErrUnreadableDir, dirPath, err)
 		return
 	}
-	for _, f := range files {
-		isDir, err := isDirOrSymlinkToDir(dirPath, f)
+	for _, entry := range entries {
+		entryPath := filepath.Join(dirPath, entry.Name())
+		isDir, dirErr := w.isDirOrSymlinkToDir(dirPath, entry)
 		// Skip invalid symlinks
-		if err != nil {
-			log.Error(ctx, "Invalid symlink", "dir", dirPath)
+		if dirErr != nil {
+			log.Error(ctx, "Invalid symlink", "dir", dirPath, "name", entry.Name())
+			if err = w.fail(KindBrokenSymlink, ErrBrokenSymlink, entryPath, dirErr); errors.Is(err, SkipThis) {
+				err = nil
+				continue
+			}
+			return
+		}
+		if stack.match(entryPath, isDir) {
+			continue
+		}
+		if isDir && !w.isDirIgnored(dirPath, entry.Name()) {
+			children = append(children, entryPath)
+			continue
+		}
+		if isDir {
 			continue
 		}
-		if isDir && !isDirIgnored(dirPath, f) && isDirReadable(dirPath, f) {
-			children = append(children, filepath.Join(dirPath, f.Name()))
+		if entry.ModTime().After(stats.ModTime) {
+			stats.ModTime = entry.ModTime()
+		}
+		if utils.IsAudioFile(entry.Name()) {
+			stats.AudioFilesCount++
 		} else {
-			if f.ModTime().After(stats.ModTime) {
-				stats.ModTime = f.ModTime()
-			}
-			if utils.IsAudioFile(f.Name()) {
-				stats.AudioFilesCount++
-			} else {
-				stats.HasPlaylist = stats.HasPlaylist || utils.IsPlaylist(f.Name())
-				stats.HasImages = stats.HasImages || utils.IsImageFile(f.Name())
-			}
+			stats.HasPlaylist = stats.HasPlaylist || utils.IsPlaylist(entry.Name())
+			stats.HasImages = stats.HasImages || utils.IsImageFile(entry.Name())
 		}
 	}
 	return
 }
 
-// isDirOrSymlinkToDir returns true if and only if the dirInfo represents a file
-// system directory, or a symbolic link to a directory. Note that if the dirInfo
-// is not a directory but is a symbolic link, this method will resolve by
-// sending a request to the operating system to follow the symbolic link.
-// Copied from github.com/karrick/godirwalk
-func isDirOrSymlinkToDir(baseDir string, dirInfo os.FileInfo) (bool, error) {
-	if dirInfo.IsDir() {
+// isDirOrSymlinkToDir returns true if and only if the entry represents a
+// directory, or a symbolic link to a directory. Note that if the entry is
+// not a directory but is a symbolic link, this method will resolve it by
+// sending a Stat request to the backing FS.
+func (w *Walker) isDirOrSymlinkToDir(baseDir string, entry storage.DirEntry) (bool, error) {
+	if entry.IsDir() {
 		return true, nil
 	}
-	if dirInfo.Mode()&os.ModeSymlink == 0 {
+	if entry.Mode()&os.ModeSymlink == 0 {
 		return false, nil
 	}
 	// Does this symlink point to a directory?
-	dirInfo, err := os.Stat(filepath.Join(baseDir, dirInfo.Name()))
+	fi, err := w.FS.Stat(filepath.Join(baseDir, entry.Name()))
 	if err != nil {
 		return false, err
 	}
-	return dirInfo.IsDir(), nil
+	return fi.IsDir(), nil
 }
 
-// isDirIgnored returns true if the directory represented by dirInfo contains an
-// `ignore` file (named after consts.SkipScanFile)
-func isDirIgnored(baseDir string, dirInfo os.FileInfo) bool {
-	if strings.HasPrefix(dirInfo.Name(), ".") {
-		return true
-	}
-	_, err := os.Stat(filepath.Join(baseDir, dirInfo.Name(), consts.SkipScanFile))
-	return err == nil
-}
-
-// isDirReadable returns true if the directory represented by dirInfo is readable
-func isDirReadable(baseDir string, dirInfo os.FileInfo) bool {
-	path := filepath.Join(baseDir, dirInfo.Name())
-	res, err := utils.IsDirReadable(path)
-	if !res {
-		log.Debug("Warning: Skipping unreadable directory", "path", path, err)
-	}
-	return res
+// isDirIgnored returns true if the directory named name is itself a
+// dot-directory. A consts.IgnoreFile inside a directory used to mean
+// "skip this directory entirely", but that's now handled selectively, by
+// ignoreStack matching that directory's own gitignore-style patterns
+// against each of its children — wholesale-skipping it here as soon as it
+// merely contains a consts.IgnoreFile would make every pattern in that
+// file moot by excluding the whole directory before any of them are ever
+// evaluated.
+func (w *Walker) isDirIgnored(_ string, name string) bool {
+	return strings.HasPrefix(name, ".")
 }
@@ -0,0 +1,160 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/deluan/navidrome/storage/basicfs"
+)
+
+// buildSyntheticTree creates a directory tree depth levels deep, with
+// fanout subdirectories per level, each containing fileCount fake audio
+// files. It returns the root path; the tree is removed via b.Cleanup.
+func buildSyntheticTree(b *testing.B, depth, fanout, fileCount int) string {
+	b.Helper()
+	root, err := ioutil.TempDir("", "navidrome-walk-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var populate func(dir string, level int)
+	populate = func(dir string, level int) {
+		for i := 0; i < fileCount; i++ {
+			name := filepath.Join(dir, fmt.Sprintf("track%d.mp3", i))
+			if err := ioutil.WriteFile(name, nil, 0600); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if level >= depth {
+			return
+		}
+		for i := 0; i < fanout; i++ {
+			sub := filepath.Join(dir, fmt.Sprintf("dir%d", i))
+			if err := os.Mkdir(sub, 0700); err != nil {
+				b.Fatal(err)
+			}
+			populate(sub, level+1)
+		}
+	}
+	populate(root, 0)
+	b.Cleanup(func() { _ = os.RemoveAll(root) })
+	return root
+}
+
+// drain reads and discards every dirStats sent on results, so the walker
+// under benchmark is never blocked waiting for a consumer.
+func drain(results walkResults) {
+	for range results {
+	}
+}
+
+func BenchmarkWalkDirTree_Sequential(b *testing.B) {
+	root := buildSyntheticTree(b, 4, 6, 20)
+	ctx := context.Background()
+	fs := basicfs.New("")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		results := make(walkResults)
+		go drain(results)
+		if err := NewWalker(1, fs, false, nil).Walk(ctx, root, results); err != nil {
+			b.Fatal(err)
+		}
+		close(results)
+	}
+}
+
+func BenchmarkWalkDirTree_Parallel(b *testing.B) {
+	root := buildSyntheticTree(b, 4, 6, 20)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		results := make(walkResults)
+		go drain(results)
+		if _, err := walkDirTree(ctx, root, false, results); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// legacyWalkFolder reproduces the goroutine-per-directory walker this
+// package replaced: each child gets its own goroutine, gated by sem, and
+// that goroutine holds its slot until its entire subtree (not just its own
+// directory read) has finished. It exists only so
+// BenchmarkWalkDirTree_Legacy has something to compare the current
+// queue-based Walker against; nothing outside this file uses it.
+func legacyWalkFolder(dir string, sem chan struct{}, results walkResults) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var stats dirStats
+	var children []string
+	for _, e := range entries {
+		if e.IsDir() {
+			children = append(children, filepath.Join(dir, e.Name()))
+			continue
+		}
+		stats.AudioFilesCount++
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range children {
+		c := c
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			legacyWalkFolder(c, sem, results)
+		}()
+	}
+	wg.Wait()
+
+	results <- stats
+}
+
+// countDirs counts every directory in root's tree, root included.
+func countDirs(b *testing.B, root string) int {
+	b.Helper()
+	n := 0
+	err := filepath.Walk(root, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return n
+}
+
+// BenchmarkWalkDirTree_Legacy benchmarks the original per-directory-goroutine
+// design against the same synthetic tree the Sequential/Parallel benchmarks
+// above use. Its semaphore has to be sized to the tree's total directory
+// count to avoid the exact deadlock this series fixed (see
+// TestWalkDirTree_DeepTreeDoesNotDeadlock): a parent holds its slot across
+// its child's entire subtree, so any size smaller than the tree's depth can
+// hang forever, and the right size isn't knowable ahead of a real scan. That
+// requirement is itself the headline problem with this design, not just a
+// benchmarking inconvenience — so the comparison below is already generous
+// to the legacy walker.
+func BenchmarkWalkDirTree_Legacy(b *testing.B) {
+	root := buildSyntheticTree(b, 4, 6, 20)
+	totalDirs := countDirs(b, root)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		results := make(walkResults, totalDirs)
+		sem := make(chan struct{}, totalDirs)
+		legacyWalkFolder(root, sem, results)
+		close(results)
+		drain(results)
+	}
+}
@@ -0,0 +1,66 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/deluan/navidrome/storage/basicfs"
+)
+
+// TestWalkDirTree_DeepTreeDoesNotDeadlock is a regression test for a
+// worker-pool design that held a slot for an entire subtree: with
+// NumWorkers < tree depth, an inner directory could never acquire a slot
+// held by its own ancestor, and the walk hung forever. Here depth is well
+// past NumWorkers (1), so a naive goroutine-per-directory-holding-a-slot
+// implementation would never complete.
+func TestWalkDirTree_DeepTreeDoesNotDeadlock(t *testing.T) {
+	root := buildDeepTree(t, 20)
+	fs := basicfs.New("")
+	ctx := context.Background()
+
+	results := make(walkResults)
+	done := make(chan error, 1)
+	go func() {
+		done <- NewWalker(1, fs, false, nil).Walk(ctx, root, results)
+	}()
+
+	var count int
+	drained := make(chan struct{})
+	go func() {
+		for range results {
+			count++
+		}
+		close(drained)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Walk returned an error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Walk did not return within 5s, likely deadlocked")
+	}
+	close(results)
+	<-drained
+
+	if count != 21 { // the root plus 20 nested subdirectories
+		t.Fatalf("got %d directories, want 21", count)
+	}
+}
+
+func buildDeepTree(t *testing.T, depth int) string {
+	t.Helper()
+	root := t.TempDir()
+	dir := root
+	for i := 0; i < depth; i++ {
+		dir = filepath.Join(dir, "d")
+		if err := os.Mkdir(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
@@ -0,0 +1,101 @@
+// Package basicfs is the storage.FS backend for music folders that live
+// on the local filesystem. It matches the behavior Navidrome had before
+// the storage.FS abstraction existed.
+package basicfs
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/deluan/navidrome/storage"
+	"github.com/karrick/godirwalk"
+)
+
+func init() {
+	storage.Register("", func(uri string) (storage.FS, error) {
+		return New(uri), nil
+	})
+}
+
+// FS is a storage.FS rooted at a path on the local filesystem.
+type FS struct {
+	root string
+}
+
+// New creates a basicfs.FS rooted at root.
+func New(root string) *FS {
+	return &FS{root: root}
+}
+
+func (fs *FS) URI() string { return fs.root }
+
+func (fs *FS) Type() string { return "basic" }
+
+func (fs *FS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(fs.abs(name))
+}
+
+func (fs *FS) Open(name string) (storage.ReadSeekCloser, error) {
+	return os.Open(fs.abs(name))
+}
+
+// ReadDir reads name's directory entries straight off the directory
+// stream (d_type on Unix), so no per-entry lstat is needed just to tell
+// files from directories.
+func (fs *FS) ReadDir(name string) ([]storage.DirEntry, error) {
+	entries, err := godirwalk.ReadDirents(fs.abs(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	dir := fs.abs(name)
+	result := make([]storage.DirEntry, len(entries))
+	for i, e := range entries {
+		result[i] = &dirEntry{dir: dir, entry: e}
+	}
+	return result, nil
+}
+
+func (fs *FS) abs(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(fs.root, name)
+}
+
+// dirEntry adapts a godirwalk.Dirent (which only knows the entry's name
+// and d_type) to storage.DirEntry, deferring the lstat needed for Mode,
+// ModTime and Stat until one of those is actually called.
+type dirEntry struct {
+	dir   string
+	entry *godirwalk.Dirent
+
+	info os.FileInfo
+	err  error
+}
+
+func (d *dirEntry) Name() string { return d.entry.Name() }
+func (d *dirEntry) IsDir() bool  { return d.entry.IsDir() }
+
+func (d *dirEntry) Mode() os.FileMode {
+	fi, err := d.Stat()
+	if err != nil {
+		return 0
+	}
+	return fi.Mode()
+}
+
+func (d *dirEntry) ModTime() time.Time {
+	fi, err := d.Stat()
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+func (d *dirEntry) Stat() (os.FileInfo, error) {
+	if d.info == nil && d.err == nil {
+		d.info, d.err = os.Lstat(filepath.Join(d.dir, d.entry.Name()))
+	}
+	return d.info, d.err
+}
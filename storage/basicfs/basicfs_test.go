@@ -0,0 +1,91 @@
+package basicfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFS_ReadDirAndOpen(t *testing.T) {
+	root := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(root, "track.mp3"), []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "subdir"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := New(root)
+	if fs.Type() != "basic" {
+		t.Errorf("Type() = %q, want %q", fs.Type(), "basic")
+	}
+	if fs.URI() != root {
+		t.Errorf("URI() = %q, want %q", fs.URI(), root)
+	}
+
+	entries, err := fs.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	var gotFile, gotDir bool
+	for _, e := range entries {
+		switch e.Name() {
+		case "track.mp3":
+			gotFile = true
+			if e.IsDir() {
+				t.Error("track.mp3 should not be a directory")
+			}
+		case "subdir":
+			gotDir = true
+			if !e.IsDir() {
+				t.Error("subdir should be a directory")
+			}
+		default:
+			t.Errorf("unexpected entry %q", e.Name())
+		}
+	}
+	if !gotFile || !gotDir {
+		t.Fatalf("missing expected entries: gotFile=%v gotDir=%v", gotFile, gotDir)
+	}
+
+	f, err := fs.Open(filepath.Join(root, "track.mp3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("Open content = %q, want %q", data, "hello")
+	}
+}
+
+func TestDirEntry_LazyStat(t *testing.T) {
+	root := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(root, "a.flac"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := New(root)
+	entries, err := fs.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	info, err := entries[0].Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name() != "a.flac" {
+		t.Errorf("Stat().Name() = %q, want %q", info.Name(), "a.flac")
+	}
+}
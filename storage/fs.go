@@ -0,0 +1,91 @@
+// Package storage abstracts away the filesystem a music folder lives on,
+// so the scanner, tag reader and artwork extractor can work the same way
+// whether the folder is on local disk, an SMB share or an S3 bucket.
+//
+// Only the scanner's directory walk (scanner.Walker) has been moved onto
+// this abstraction so far. This tree doesn't contain a tag reader or
+// artwork extractor package to refactor yet; when one is added, it should
+// take a storage.FS (or just the ReadSeekCloser from FS.Open) instead of a
+// path, for exactly the reason DirEntry and ReadSeekCloser below exist: so
+// a remote backend like s3fs never has to stage a file on local disk just
+// to read it once.
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DirEntry is a lightweight stand-in for os.FileInfo as returned by
+// FS.ReadDir. Name and IsDir must be available without any extra syscalls
+// (e.g. from readdir's d_type on Unix). Mode, ModTime and Stat may need one
+// (basicfs performs a single lstat on the first of them called); it must be
+// done lazily, never up front in ReadDir.
+type DirEntry interface {
+	Name() string
+	IsDir() bool
+	Mode() os.FileMode
+	ModTime() time.Time
+	Stat() (os.FileInfo, error)
+}
+
+// ReadSeekCloser is what FS.Open returns. Tag readers and artwork
+// extractors are expected to consume it directly instead of requiring a
+// local path, so that remote backends never need to stage files on disk.
+type ReadSeekCloser interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// FS is implemented by each supported music folder backend.
+type FS interface {
+	Stat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]DirEntry, error)
+	Open(name string) (ReadSeekCloser, error)
+
+	// URI returns the configured URI this FS was created from, e.g.
+	// "s3://bucket/prefix" or "/music".
+	URI() string
+	// Type returns a short backend identifier, e.g. "basic", "s3", "smb".
+	Type() string
+}
+
+// Factory creates an FS rooted at the given URI. Backends register their
+// factory under a URI scheme using Register.
+type Factory func(uri string) (FS, error)
+
+var registry = map[string]Factory{}
+
+// Register associates a URI scheme (e.g. "s3", "smb") with a Factory.
+// Backends call this from an init() function. The empty scheme is used as
+// the fallback for plain filesystem paths.
+func Register(scheme string, factory Factory) {
+	registry[scheme] = factory
+}
+
+// New parses uri's scheme and returns the FS created by the Factory
+// registered for it. Paths with no scheme (e.g. "/music") are routed to
+// the "" (local) factory.
+func New(uri string) (FS, error) {
+	scheme := schemeOf(uri)
+	factory, ok := registry[scheme]
+	if !ok {
+		return nil, fmt.Errorf("storage: no backend registered for scheme %q", scheme)
+	}
+	return factory(uri)
+}
+
+func schemeOf(uri string) string {
+	if !strings.Contains(uri, "://") {
+		return ""
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme
+}
@@ -0,0 +1,199 @@
+// Package s3fs is the storage.FS backend for music folders hosted in an
+// S3-compatible object store, registered under the "s3" URI scheme (e.g.
+// "s3://bucket/prefix").
+package s3fs
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/deluan/navidrome/storage"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+func init() {
+	storage.Register("s3", func(uri string) (storage.FS, error) {
+		return New(uri)
+	})
+}
+
+// FS is a storage.FS backed by an S3-compatible bucket. Credentials and
+// endpoint are taken from the environment, so the MusicFolder URI only
+// needs to carry the bucket and prefix: credentials from the AWS_* variables
+// (minio-go's credentials.NewEnvAWS), the endpoint from ND_S3_ENDPOINT
+// (default "s3.amazonaws.com"), and TLS is turned off when ND_S3_INSECURE
+// is set to any non-empty value.
+type FS struct {
+	uri    string
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// New parses uri (of the form "s3://bucket/prefix") and returns an FS
+// backed by it.
+func New(uri string) (*FS, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("s3fs: invalid uri %q: %w", uri, err)
+	}
+	if u.Scheme != "s3" {
+		return nil, fmt.Errorf("s3fs: unsupported scheme %q", u.Scheme)
+	}
+	endpoint := os.Getenv("ND_S3_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "s3.amazonaws.com"
+	}
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewEnvAWS(),
+		Secure: os.Getenv("ND_S3_INSECURE") == "",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("s3fs: creating client for %q: %w", uri, err)
+	}
+	return &FS{
+		uri:    uri,
+		client: client,
+		bucket: u.Host,
+		prefix: strings.Trim(u.Path, "/"),
+	}, nil
+}
+
+func (fs *FS) URI() string  { return fs.uri }
+func (fs *FS) Type() string { return "s3" }
+
+func (fs *FS) key(name string) string {
+	return path.Join(fs.prefix, name)
+}
+
+// Stat stats name as an object first. S3 has no real directories, though:
+// a "directory" only exists as the common prefix of other objects' keys,
+// with no object actually stored at that exact key unless something went
+// out of its way to PUT a zero-byte placeholder there (most tools that
+// populate a bucket, e.g. aws-cli, rclone, s3cmd, don't). So when the
+// object lookup itself fails (whatever the error, NotFound included), name
+// is also tried as a directory prefix before giving up.
+func (fs *FS) Stat(name string) (os.FileInfo, error) {
+	key := fs.key(name)
+	info, err := fs.client.StatObject(context.Background(), fs.bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return &objectInfo{name: path.Base(name), info: info}, nil
+	}
+	if isDir, dirErr := fs.isVirtualDir(key); dirErr == nil && isDir {
+		return &objectInfo{name: path.Base(name), isDir: true}, nil
+	}
+	return nil, fmt.Errorf("s3fs: stat %q: %w", name, err)
+}
+
+// isVirtualDir reports whether at least one object exists with key as a
+// '/'-delimited prefix, which is how S3 represents a directory that has no
+// placeholder object of its own.
+func (fs *FS) isVirtualDir(key string) (bool, error) {
+	prefix := key
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for obj := range fs.client.ListObjects(ctx, fs.bucket, minio.ListObjectsOptions{Prefix: prefix, MaxKeys: 1}) {
+		if obj.Err != nil {
+			return false, obj.Err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (fs *FS) Open(name string) (storage.ReadSeekCloser, error) {
+	obj, err := fs.client.GetObject(context.Background(), fs.bucket, fs.key(name), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("s3fs: open %q: %w", name, err)
+	}
+	return obj, nil
+}
+
+// ReadDir lists the "directory" at name, treating '/' delimited common
+// prefixes as subdirectories, the same way the S3 console does.
+func (fs *FS) ReadDir(name string) ([]storage.DirEntry, error) {
+	prefix := fs.key(name)
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	ctx := context.Background()
+	var entries []storage.DirEntry
+	// Recursive defaults to false, which is what we want here: minio-go lists
+	// one level at a time (delimited on "/"), returning subdirectories as
+	// common-prefix entries whose Key ends in "/", rather than walking the
+	// whole bucket under prefix.
+	for obj := range fs.client.ListObjects(ctx, fs.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("s3fs: list %q: %w", name, obj.Err)
+		}
+		isDir := obj.Key == "" && obj.Size == 0 || strings.HasSuffix(obj.Key, "/")
+		key := strings.TrimSuffix(obj.Key, "/")
+		entries = append(entries, &dirEntry{
+			name:    path.Base(key),
+			isDir:   isDir,
+			size:    obj.Size,
+			modTime: obj.LastModified,
+		})
+	}
+	return entries, nil
+}
+
+type dirEntry struct {
+	name    string
+	isDir   bool
+	size    int64
+	modTime time.Time
+}
+
+func (d *dirEntry) Name() string       { return d.name }
+func (d *dirEntry) IsDir() bool        { return d.isDir }
+func (d *dirEntry) ModTime() time.Time { return d.modTime }
+func (d *dirEntry) Mode() os.FileMode {
+	if d.isDir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+func (d *dirEntry) Stat() (os.FileInfo, error) {
+	return &objectInfo{name: d.name, size: d.size, modTime: d.modTime, isDir: d.isDir}, nil
+}
+
+// objectInfo adapts an S3 object (or common prefix) to os.FileInfo.
+type objectInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+	info    minio.ObjectInfo
+}
+
+func (o *objectInfo) Name() string { return o.name }
+func (o *objectInfo) Size() int64 {
+	if o.info.Key != "" {
+		return o.info.Size
+	}
+	return o.size
+}
+func (o *objectInfo) Mode() os.FileMode {
+	if o.isDir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+func (o *objectInfo) ModTime() time.Time {
+	if o.info.Key != "" {
+		return o.info.LastModified
+	}
+	return o.modTime
+}
+func (o *objectInfo) IsDir() bool      { return o.isDir }
+func (o *objectInfo) Sys() interface{} { return o.info }
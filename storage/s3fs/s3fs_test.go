@@ -0,0 +1,49 @@
+package s3fs
+
+import "testing"
+
+func TestNew_ParsesBucketAndPrefix(t *testing.T) {
+	fs, err := New("s3://my-bucket/music/library")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs.bucket != "my-bucket" {
+		t.Errorf("bucket = %q, want %q", fs.bucket, "my-bucket")
+	}
+	if fs.prefix != "music/library" {
+		t.Errorf("prefix = %q, want %q", fs.prefix, "music/library")
+	}
+	if fs.Type() != "s3" {
+		t.Errorf("Type() = %q, want %q", fs.Type(), "s3")
+	}
+	if fs.URI() != "s3://my-bucket/music/library" {
+		t.Errorf("URI() = %q, want %q", fs.URI(), "s3://my-bucket/music/library")
+	}
+}
+
+func TestNew_RejectsOtherSchemes(t *testing.T) {
+	if _, err := New("smb://host/share"); err == nil {
+		t.Error("expected an error for a non-s3 scheme, got nil")
+	}
+}
+
+func TestFS_Key(t *testing.T) {
+	fs, err := New("s3://bucket/prefix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.key("Artist/Album/track.flac"); got != "prefix/Artist/Album/track.flac" {
+		t.Errorf("key() = %q, want %q", got, "prefix/Artist/Album/track.flac")
+	}
+}
+
+func TestDirEntry_Mode(t *testing.T) {
+	dir := &dirEntry{name: "Album", isDir: true}
+	if !dir.Mode().IsDir() {
+		t.Error("a directory dirEntry's Mode() should report IsDir()")
+	}
+	file := &dirEntry{name: "track.flac", isDir: false}
+	if file.Mode().IsDir() {
+		t.Error("a file dirEntry's Mode() should not report IsDir()")
+	}
+}
@@ -0,0 +1,183 @@
+// Package ignore implements gitignore/dockerignore-style pattern matching,
+// used by the scanner to let users exclude parts of a music folder via
+// `.ndignore` files or the global IgnoredPatterns config option.
+package ignore
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Pattern is a single compiled gitignore-style rule.
+type Pattern struct {
+	Negate bool // line started with "!"
+	// DirOnly is true if the pattern only matches directories (it ended in "/")
+	DirOnly bool
+	// anchored patterns only match relative to the directory the pattern was
+	// declared in; patterns with no "/" (other than a trailing one) match at
+	// any depth.
+	anchored bool
+	re       *regexp.Regexp
+}
+
+// Match reports whether relPath (slash-separated, relative to the
+// directory the pattern was declared in) matches this pattern. isDir must
+// reflect whether relPath itself is a directory.
+func (p *Pattern) Match(relPath string, isDir bool) bool {
+	if p.DirOnly && !isDir {
+		return false
+	}
+	if p.re.MatchString(relPath) {
+		return true
+	}
+	if p.anchored {
+		return false
+	}
+	return p.re.MatchString(filepath.Base(relPath))
+}
+
+// Compile parses a single line of a `.ndignore`/`.gitignore`-style file.
+// It returns a nil Pattern (and nil error) for blank lines and comments.
+func Compile(line string) (*Pattern, error) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	p := &Pattern{}
+	if strings.HasPrefix(line, "!") {
+		p.Negate = true
+		line = line[1:]
+	}
+	if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.DirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if strings.HasPrefix(line, "/") {
+		p.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	} else if strings.Contains(line, "/") {
+		p.anchored = true
+	}
+
+	re, err := regexp.Compile(translate(line))
+	if err != nil {
+		return nil, err
+	}
+	p.re = re
+	return p, nil
+}
+
+// translate converts a gitignore glob pattern into an anchored
+// regular expression: "*" and "?" don't cross "/", while "**" does (either
+// as a whole segment, matching zero or more directories, or combined with
+// other characters, matching across any number of "/").
+func translate(pattern string) string {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				switch {
+				case i+2 < len(pattern) && pattern[i+2] == '/':
+					re.WriteString("(.*/)?")
+					i += 2
+				case i == 0:
+					re.WriteString(".*")
+					i++
+				default:
+					re.WriteString(".*")
+					i++
+				}
+				continue
+			}
+			re.WriteString("[^/]*")
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			j := i
+			for j < len(pattern) && pattern[j] != ']' {
+				j++
+			}
+			if j < len(pattern) {
+				re.WriteString(pattern[i : j+1])
+				i = j
+				continue
+			}
+			re.WriteString("\\[")
+		case '.', '+', '(', ')', '{', '}', '^', '$', '|', '\\':
+			re.WriteByte('\\')
+			re.WriteByte(c)
+		default:
+			re.WriteByte(c)
+		}
+	}
+	re.WriteString("$")
+	return re.String()
+}
+
+// Set is an ordered collection of Patterns parsed from a single
+// `.ndignore` file (or the global IgnoredPatterns config option). As in
+// gitignore, later patterns override earlier ones for a given path.
+type Set struct {
+	Patterns []*Pattern
+}
+
+// Parse compiles each of lines into a Set, skipping blank lines and comments.
+func Parse(lines []string) (*Set, error) {
+	set := &Set{}
+	for _, line := range lines {
+		p, err := Compile(line)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			set.Patterns = append(set.Patterns, p)
+		}
+	}
+	return set, nil
+}
+
+// ParseReader reads newline-separated patterns from r and compiles them
+// into a Set, the same as Parse.
+func ParseReader(r io.Reader) (*Set, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return Parse(lines)
+}
+
+// Match reports whether relPath (slash-separated, relative to the
+// directory this Set was parsed for) is ignored, applying patterns in
+// order so a later match (including a negation) overrides an earlier one.
+func (s *Set) Match(relPath string, isDir bool) bool {
+	if s == nil {
+		return false
+	}
+	relPath = filepath.ToSlash(relPath)
+	ignored := false
+	for _, p := range s.Patterns {
+		if p.Match(relPath, isDir) {
+			ignored = !p.Negate
+		}
+	}
+	return ignored
+}
+
+// Empty reports whether the Set has no patterns at all.
+func (s *Set) Empty() bool {
+	return s == nil || len(s.Patterns) == 0
+}
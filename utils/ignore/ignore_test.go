@@ -0,0 +1,81 @@
+package ignore
+
+import "testing"
+
+func TestSet_Match(t *testing.T) {
+	set, err := Parse([]string{
+		"# a comment, and a blank line below",
+		"",
+		"**/Demos/",
+		"*.cue",
+		"!keepme.cue",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"Artist/Album/Demos", true, true},
+		{"Demos", true, true},
+		{"Artist/Album/track.cue", false, true},
+		{"keepme.cue", false, false},
+		{"Artist/Album/track.flac", false, false},
+		{"Demos", false, false}, // "Demos/" is directory-only
+	}
+	for _, c := range cases {
+		if got := set.Match(c.path, c.isDir); got != c.want {
+			t.Errorf("Match(%q, isDir=%v) = %v, want %v", c.path, c.isDir, got, c.want)
+		}
+	}
+}
+
+func TestSet_Match_NegationOverridesEarlierPattern(t *testing.T) {
+	set, err := Parse([]string{"*.flac", "!keepme.flac"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Match("keepme.flac", false) {
+		t.Error("keepme.flac should have been re-included by the negated pattern")
+	}
+	if !set.Match("other.flac", false) {
+		t.Error("other.flac should still be ignored")
+	}
+}
+
+func TestSet_Empty(t *testing.T) {
+	set, err := Parse(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !set.Empty() {
+		t.Error("Set parsed from no lines should be Empty")
+	}
+	if set.Match("anything", false) {
+		t.Error("an Empty Set should never match")
+	}
+}
+
+func TestCompile_AnchoredVsUnanchored(t *testing.T) {
+	anchored, err := Compile("/root.cue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !anchored.Match("root.cue", false) {
+		t.Error("anchored pattern should match at the root")
+	}
+	if anchored.Match("nested/root.cue", false) {
+		t.Error("anchored pattern should not match in a subdirectory")
+	}
+
+	unanchored, err := Compile("*.cue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !unanchored.Match("nested/deep/track.cue", false) {
+		t.Error("unanchored pattern should match at any depth")
+	}
+}